@@ -0,0 +1,59 @@
+// Copyright 2019 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package db is a thin wrapper around database/sql so the configstore
+// read/write layers share one transaction type instead of passing
+// *sql.Tx around directly.
+package db
+
+import "database/sql"
+
+type DB struct {
+	db *sql.DB
+}
+
+func NewDB(sqlDB *sql.DB) *DB {
+	return &DB{db: sqlDB}
+}
+
+// Tx wraps a single *sql.Tx for the lifetime of one Do call.
+type Tx struct {
+	tx *sql.Tx
+}
+
+func (t *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.tx.Exec(query, args...)
+}
+
+func (t *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.tx.Query(query, args...)
+}
+
+func (t *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return t.tx.QueryRow(query, args...)
+}
+
+// Do runs f inside a transaction, committing on a nil return and
+// rolling back otherwise.
+func (d *DB) Do(f func(tx *Tx) error) error {
+	sqlTx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := f(&Tx{tx: sqlTx}); err != nil {
+		sqlTx.Rollback()
+		return err
+	}
+	return sqlTx.Commit()
+}
@@ -0,0 +1,180 @@
+// Copyright 2019 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+	"net"
+
+	"github.com/sorintlab/agola/internal/services/configstore/api/proto"
+	"github.com/sorintlab/agola/internal/services/configstore/service"
+	"github.com/sorintlab/agola/internal/services/types"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// ConfigstoreGRPCServer implements proto.ConfigstoreServer against the
+// same service.SecretService the HTTP handlers use, so both transports
+// stay in lockstep.
+type ConfigstoreGRPCServer struct {
+	log *zap.SugaredLogger
+	ss  *service.SecretService
+}
+
+func NewConfigstoreGRPCServer(logger *zap.Logger, ss *service.SecretService) *ConfigstoreGRPCServer {
+	return &ConfigstoreGRPCServer{log: logger.Sugar(), ss: ss}
+}
+
+func (s *ConfigstoreGRPCServer) GetSecret(ctx context.Context, req *proto.GetSecretRequest) (*proto.Secret, error) {
+	secret, err := s.ss.GetSecretByID(ctx, req.SecretID)
+	if err != nil {
+		return nil, err
+	}
+	return secretToProto(secret), nil
+}
+
+func (s *ConfigstoreGRPCServer) ListSecrets(ctx context.Context, req *proto.ListSecretsRequest) (*proto.ListSecretsResponse, error) {
+	secrets, err := s.ss.GetSecrets(ctx, configTypeFromProto(req.ParentType), req.ParentRef, req.Tree)
+	if err != nil {
+		return nil, err
+	}
+	resp := &proto.ListSecretsResponse{Secrets: make([]*proto.Secret, len(secrets))}
+	for i, sec := range secrets {
+		resp.Secrets[i] = secretToProto(sec)
+	}
+	return resp, nil
+}
+
+func (s *ConfigstoreGRPCServer) CreateSecret(ctx context.Context, req *proto.CreateSecretRequest) (*proto.Secret, error) {
+	secret := secretFromProto(req.Secret)
+	secret.Parent.Type = configTypeFromProto(req.ParentType)
+	secret.Parent.ID = req.ParentRef
+
+	secret, err := s.ss.CreateSecret(ctx, secret, req.BackendName)
+	if err != nil {
+		return nil, err
+	}
+	return secretToProto(secret), nil
+}
+
+func (s *ConfigstoreGRPCServer) DeleteSecret(ctx context.Context, req *proto.DeleteSecretRequest) (*proto.DeleteSecretResponse, error) {
+	if err := s.ss.DeleteSecret(ctx, configTypeFromProto(req.ParentType), req.ParentRef, req.SecretName); err != nil {
+		return nil, err
+	}
+	return &proto.DeleteSecretResponse{}, nil
+}
+
+// WatchSecrets streams the current set of matching secrets, each as a
+// CREATED event. It does not yet stream subsequent create/update/delete
+// events; the readDB has no change feed to drive that, so callers that
+// need up-to-date state must re-issue the call.
+func (s *ConfigstoreGRPCServer) WatchSecrets(req *proto.ListSecretsRequest, stream proto.Configstore_WatchSecretsServer) error {
+	secrets, err := s.ss.GetSecrets(stream.Context(), configTypeFromProto(req.ParentType), req.ParentRef, req.Tree)
+	if err != nil {
+		return err
+	}
+	for _, sec := range secrets {
+		if err := stream.Send(&proto.SecretEvent{EventType: proto.SecretEventType_CREATED, Secret: secretToProto(sec)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListenAndServeGRPC starts the gRPC API on its own listener, separate
+// from the HTTP/JSON API served by the handlers in this package. It
+// forces proto.JSONCodec on this server only, since the hand-written
+// message structs in the proto package don't implement proto.Message; other
+// gRPC servers/clients in the same process keep using the default
+// protobuf codec.
+func ListenAndServeGRPC(addr string, srv *ConfigstoreGRPCServer) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	grpcServer := grpc.NewServer(grpc.ForceServerCodec(proto.JSONCodec{}))
+	proto.RegisterConfigstoreServer(grpcServer, srv)
+
+	return grpcServer.Serve(lis)
+}
+
+func secretToProto(secret *types.Secret) *proto.Secret {
+	if secret == nil {
+		return nil
+	}
+	return &proto.Secret{
+		ID:   secret.ID,
+		Name: secret.Name,
+		Parent: &proto.Parent{
+			Type: configTypeToProto(secret.Parent.Type),
+			ID:   secret.Parent.ID,
+			Path: secret.Parent.Path,
+		},
+		Type: string(secret.Type),
+		Data: secret.Data,
+	}
+}
+
+func secretFromProto(p *proto.Secret) *types.Secret {
+	if p == nil {
+		return &types.Secret{}
+	}
+	secret := &types.Secret{
+		ID:   p.ID,
+		Name: p.Name,
+		Type: types.SecretType(p.Type),
+		Data: p.Data,
+	}
+	if p.Parent != nil {
+		secret.Parent.Type = configTypeFromProto(p.Parent.Type)
+		secret.Parent.ID = p.Parent.ID
+		secret.Parent.Path = p.Parent.Path
+	}
+	return secret
+}
+
+// configTypeToProto and configTypeFromProto translate between
+// types.ConfigType (ProjectGroup=0, Project=1, Org=2) and the proto3
+// ConfigType enum (CONFIG_TYPE_UNSPECIFIED=0, PROJECT_GROUP=1, PROJECT=2,
+// ORG=3), which are deliberately NOT numerically aligned: proto3 reserves
+// 0 for "unspecified" on every enum. A raw numeric cast between them
+// silently shifts every value by one.
+func configTypeToProto(t types.ConfigType) proto.ConfigType {
+	switch t {
+	case types.ConfigTypeProjectGroup:
+		return proto.ConfigType_PROJECT_GROUP
+	case types.ConfigTypeProject:
+		return proto.ConfigType_PROJECT
+	case types.ConfigTypeOrg:
+		return proto.ConfigType_ORG
+	default:
+		return proto.ConfigType_CONFIG_TYPE_UNSPECIFIED
+	}
+}
+
+func configTypeFromProto(t proto.ConfigType) types.ConfigType {
+	switch t {
+	case proto.ConfigType_PROJECT_GROUP:
+		return types.ConfigTypeProjectGroup
+	case proto.ConfigType_PROJECT:
+		return types.ConfigTypeProject
+	case proto.ConfigType_ORG:
+		return types.ConfigTypeOrg
+	default:
+		return types.ConfigTypeProjectGroup
+	}
+}
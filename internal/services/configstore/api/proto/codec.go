@@ -0,0 +1,42 @@
+// Copyright 2019 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+	"encoding/json"
+)
+
+// JSONCodec marshals gRPC messages as JSON instead of protobuf wire
+// format. The messages in this package are plain structs maintained by
+// hand rather than generated from configstore.proto by protoc, so they
+// don't implement proto.Message and can't go through grpc-go's default
+// protobuf codec.
+//
+// It's named "json", not "proto": registering it under "proto" would
+// replace grpc-go's default codec for every server and client in the
+// process, silently breaking any unrelated gRPC traffic sharing the
+// binary. Callers must instead force it explicitly on this server via
+// grpc.ForceServerCodec, as ListenAndServeGRPC does.
+type JSONCodec struct{}
+
+func (JSONCodec) Name() string { return "json" }
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
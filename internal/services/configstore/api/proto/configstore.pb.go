@@ -0,0 +1,72 @@
+// Package proto holds the message and service types for
+// configstore.proto. They're maintained by hand rather than generated
+// by protoc, and sent over the wire with JSONCodec (see codec.go)
+// instead of real protobuf encoding, so keep this file's types in sync
+// with configstore.proto by hand when the RPC surface changes.
+package proto
+
+type ConfigType int32
+
+const (
+	ConfigType_CONFIG_TYPE_UNSPECIFIED ConfigType = 0
+	ConfigType_PROJECT_GROUP           ConfigType = 1
+	ConfigType_PROJECT                 ConfigType = 2
+	ConfigType_ORG                     ConfigType = 3
+)
+
+type SecretEventType int32
+
+const (
+	SecretEventType_SECRET_EVENT_TYPE_UNSPECIFIED SecretEventType = 0
+	SecretEventType_CREATED                       SecretEventType = 1
+	SecretEventType_UPDATED                       SecretEventType = 2
+	SecretEventType_DELETED                       SecretEventType = 3
+)
+
+type Parent struct {
+	Type ConfigType
+	ID   string
+	Path string
+}
+
+type Secret struct {
+	ID     string
+	Name   string
+	Parent *Parent
+	Type   string
+	Data   map[string]string
+}
+
+type GetSecretRequest struct {
+	SecretID string
+}
+
+type ListSecretsRequest struct {
+	ParentType ConfigType
+	ParentRef  string
+	Tree       bool
+}
+
+type ListSecretsResponse struct {
+	Secrets []*Secret
+}
+
+type CreateSecretRequest struct {
+	ParentType  ConfigType
+	ParentRef   string
+	Secret      *Secret
+	BackendName string
+}
+
+type DeleteSecretRequest struct {
+	ParentType ConfigType
+	ParentRef  string
+	SecretName string
+}
+
+type DeleteSecretResponse struct{}
+
+type SecretEvent struct {
+	EventType SecretEventType
+	Secret    *Secret
+}
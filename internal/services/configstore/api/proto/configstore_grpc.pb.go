@@ -0,0 +1,207 @@
+// configstore_grpc.pb.go wires the message types in configstore.pb.go
+// to grpc-go, by hand rather than via protoc-gen-go-grpc; see the
+// package comment in configstore.pb.go.
+// source: configstore.proto
+
+package proto
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+type ConfigstoreClient interface {
+	GetSecret(ctx context.Context, in *GetSecretRequest, opts ...grpc.CallOption) (*Secret, error)
+	ListSecrets(ctx context.Context, in *ListSecretsRequest, opts ...grpc.CallOption) (*ListSecretsResponse, error)
+	CreateSecret(ctx context.Context, in *CreateSecretRequest, opts ...grpc.CallOption) (*Secret, error)
+	DeleteSecret(ctx context.Context, in *DeleteSecretRequest, opts ...grpc.CallOption) (*DeleteSecretResponse, error)
+	WatchSecrets(ctx context.Context, in *ListSecretsRequest, opts ...grpc.CallOption) (Configstore_WatchSecretsClient, error)
+}
+
+type configstoreClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewConfigstoreClient(cc *grpc.ClientConn) ConfigstoreClient {
+	return &configstoreClient{cc: cc}
+}
+
+func (c *configstoreClient) GetSecret(ctx context.Context, in *GetSecretRequest, opts ...grpc.CallOption) (*Secret, error) {
+	out := new(Secret)
+	if err := c.cc.Invoke(ctx, "/configstore.Configstore/GetSecret", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configstoreClient) ListSecrets(ctx context.Context, in *ListSecretsRequest, opts ...grpc.CallOption) (*ListSecretsResponse, error) {
+	out := new(ListSecretsResponse)
+	if err := c.cc.Invoke(ctx, "/configstore.Configstore/ListSecrets", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configstoreClient) CreateSecret(ctx context.Context, in *CreateSecretRequest, opts ...grpc.CallOption) (*Secret, error) {
+	out := new(Secret)
+	if err := c.cc.Invoke(ctx, "/configstore.Configstore/CreateSecret", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configstoreClient) DeleteSecret(ctx context.Context, in *DeleteSecretRequest, opts ...grpc.CallOption) (*DeleteSecretResponse, error) {
+	out := new(DeleteSecretResponse)
+	if err := c.cc.Invoke(ctx, "/configstore.Configstore/DeleteSecret", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configstoreClient) WatchSecrets(ctx context.Context, in *ListSecretsRequest, opts ...grpc.CallOption) (Configstore_WatchSecretsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Configstore_serviceDesc.Streams[0], "/configstore.Configstore/WatchSecrets", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &configstoreWatchSecretsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Configstore_WatchSecretsClient interface {
+	Recv() (*SecretEvent, error)
+	grpc.ClientStream
+}
+
+type configstoreWatchSecretsClient struct {
+	grpc.ClientStream
+}
+
+func (x *configstoreWatchSecretsClient) Recv() (*SecretEvent, error) {
+	m := new(SecretEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ConfigstoreServer is the server API for the Configstore service.
+// Implementations live in the configstore api package, against the same
+// readdb/command-backed service.SecretService the HTTP handlers use.
+type ConfigstoreServer interface {
+	GetSecret(context.Context, *GetSecretRequest) (*Secret, error)
+	ListSecrets(context.Context, *ListSecretsRequest) (*ListSecretsResponse, error)
+	CreateSecret(context.Context, *CreateSecretRequest) (*Secret, error)
+	DeleteSecret(context.Context, *DeleteSecretRequest) (*DeleteSecretResponse, error)
+	WatchSecrets(*ListSecretsRequest, Configstore_WatchSecretsServer) error
+}
+
+type Configstore_WatchSecretsServer interface {
+	Send(*SecretEvent) error
+	grpc.ServerStream
+}
+
+type configstoreWatchSecretsServer struct {
+	grpc.ServerStream
+}
+
+func (x *configstoreWatchSecretsServer) Send(m *SecretEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func RegisterConfigstoreServer(s *grpc.Server, srv ConfigstoreServer) {
+	s.RegisterService(&_Configstore_serviceDesc, srv)
+}
+
+func _Configstore_GetSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigstoreServer).GetSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/configstore.Configstore/GetSecret"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigstoreServer).GetSecret(ctx, req.(*GetSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Configstore_ListSecrets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSecretsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigstoreServer).ListSecrets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/configstore.Configstore/ListSecrets"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigstoreServer).ListSecrets(ctx, req.(*ListSecretsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Configstore_CreateSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigstoreServer).CreateSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/configstore.Configstore/CreateSecret"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigstoreServer).CreateSecret(ctx, req.(*CreateSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Configstore_DeleteSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigstoreServer).DeleteSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/configstore.Configstore/DeleteSecret"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigstoreServer).DeleteSecret(ctx, req.(*DeleteSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Configstore_WatchSecrets_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListSecretsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ConfigstoreServer).WatchSecrets(m, &configstoreWatchSecretsServer{stream})
+}
+
+var _Configstore_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "configstore.Configstore",
+	HandlerType: (*ConfigstoreServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "GetSecret", Handler: _Configstore_GetSecret_Handler},
+		{MethodName: "ListSecrets", Handler: _Configstore_ListSecrets_Handler},
+		{MethodName: "CreateSecret", Handler: _Configstore_CreateSecret_Handler},
+		{MethodName: "DeleteSecret", Handler: _Configstore_DeleteSecret_Handler},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchSecrets",
+			Handler:       _Configstore_WatchSecrets_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "configstore.proto",
+}
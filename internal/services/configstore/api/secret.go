@@ -16,11 +16,13 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
-	"github.com/sorintlab/agola/internal/db"
 	"github.com/sorintlab/agola/internal/services/configstore/command"
-	"github.com/sorintlab/agola/internal/services/configstore/readdb"
+	"github.com/sorintlab/agola/internal/services/configstore/service"
 	"github.com/sorintlab/agola/internal/services/types"
 
 	"github.com/gorilla/mux"
@@ -28,24 +30,19 @@ import (
 )
 
 type SecretHandler struct {
-	log    *zap.SugaredLogger
-	readDB *readdb.ReadDB
+	log *zap.SugaredLogger
+	ss  *service.SecretService
 }
 
-func NewSecretHandler(logger *zap.Logger, readDB *readdb.ReadDB) *SecretHandler {
-	return &SecretHandler{log: logger.Sugar(), readDB: readDB}
+func NewSecretHandler(logger *zap.Logger, ss *service.SecretService) *SecretHandler {
+	return &SecretHandler{log: logger.Sugar(), ss: ss}
 }
 
 func (h *SecretHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	secretID := vars["secretid"]
 
-	var secret *types.Secret
-	err := h.readDB.Do(func(tx *db.Tx) error {
-		var err error
-		secret, err = h.readDB.GetSecretByID(tx, secretID)
-		return err
-	})
+	secret, err := h.ss.GetSecretByID(r.Context(), secretID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -63,12 +60,12 @@ func (h *SecretHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 type SecretsHandler struct {
-	log    *zap.SugaredLogger
-	readDB *readdb.ReadDB
+	log *zap.SugaredLogger
+	ss  *service.SecretService
 }
 
-func NewSecretsHandler(logger *zap.Logger, readDB *readdb.ReadDB) *SecretsHandler {
-	return &SecretsHandler{log: logger.Sugar(), readDB: readDB}
+func NewSecretsHandler(logger *zap.Logger, ss *service.SecretService) *SecretsHandler {
+	return &SecretsHandler{log: logger.Sugar(), ss: ss}
 }
 
 func (h *SecretsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -82,27 +79,7 @@ func (h *SecretsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var secrets []*types.Secret
-	err = h.readDB.Do(func(tx *db.Tx) error {
-		parentID, err := h.readDB.ResolveConfigID(tx, parentType, parentRef)
-		if err != nil {
-			return err
-		}
-		if tree {
-			secrets, err = h.readDB.GetSecretsTree(tx, parentType, parentID)
-		} else {
-			secrets, err = h.readDB.GetSecrets(tx, parentID)
-		}
-		// populate parent path
-		for _, s := range secrets {
-			pp, err := h.readDB.GetParentPath(tx, s.Parent.Type, s.Parent.ID)
-			if err != nil {
-				return err
-			}
-			s.Parent.Path = pp
-		}
-		return err
-	})
+	secrets, err := h.ss.GetSecrets(r.Context(), parentType, parentRef, tree)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -115,13 +92,12 @@ func (h *SecretsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 type CreateSecretHandler struct {
-	log    *zap.SugaredLogger
-	ch     *command.CommandHandler
-	readDB *readdb.ReadDB
+	log *zap.SugaredLogger
+	ss  *service.SecretService
 }
 
-func NewCreateSecretHandler(logger *zap.Logger, ch *command.CommandHandler) *CreateSecretHandler {
-	return &CreateSecretHandler{log: logger.Sugar(), ch: ch}
+func NewCreateSecretHandler(logger *zap.Logger, ss *service.SecretService) *CreateSecretHandler {
+	return &CreateSecretHandler{log: logger.Sugar(), ss: ss}
 }
 
 func (h *CreateSecretHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -142,7 +118,9 @@ func (h *CreateSecretHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	secret.Parent.Type = parentType
 	secret.Parent.ID = parentRef
 
-	secret, err = h.ch.CreateSecret(ctx, secret)
+	backendName := r.URL.Query().Get("backend")
+
+	secret, err = h.ss.CreateSecret(ctx, secret, backendName)
 	if httpError(w, err) {
 		h.log.Errorf("err: %+v", err)
 		return
@@ -156,11 +134,11 @@ func (h *CreateSecretHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 
 type DeleteSecretHandler struct {
 	log *zap.SugaredLogger
-	ch  *command.CommandHandler
+	ss  *service.SecretService
 }
 
-func NewDeleteSecretHandler(logger *zap.Logger, ch *command.CommandHandler) *DeleteSecretHandler {
-	return &DeleteSecretHandler{log: logger.Sugar(), ch: ch}
+func NewDeleteSecretHandler(logger *zap.Logger, ss *service.SecretService) *DeleteSecretHandler {
+	return &DeleteSecretHandler{log: logger.Sugar(), ss: ss}
 }
 
 func (h *DeleteSecretHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -174,8 +152,72 @@ func (h *DeleteSecretHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = h.ch.DeleteSecret(ctx, parentType, parentRef, secretName)
+	err = h.ss.DeleteSecret(ctx, parentType, parentRef, secretName)
+	if httpError(w, err) {
+		h.log.Errorf("err: %+v", err)
+	}
+}
+
+// UpdateSecretHandler updates a secret's value/type in place and/or
+// reparents it to a new project/org, atomically within a single readdb
+// transaction. An optional "If-Match" header carrying the secret's
+// current revision guards against racing with a concurrent update; a
+// mismatch is reported as a 409. Without it the update is unconditional.
+type UpdateSecretHandler struct {
+	log *zap.SugaredLogger
+	ss  *service.SecretService
+}
+
+func NewUpdateSecretHandler(logger *zap.Logger, ss *service.SecretService) *UpdateSecretHandler {
+	return &UpdateSecretHandler{log: logger.Sugar(), ss: ss}
+}
+
+func (h *UpdateSecretHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	secretName := vars["secretname"]
+
+	parentType, parentRef, err := GetConfigTypeRef(r)
+	if httpError(w, err) {
+		h.log.Errorf("err: %+v", err)
+		return
+	}
+
+	var expectedRevision int64
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		expectedRevision, err = strconv.ParseInt(strings.Trim(ifMatch, `"`), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid If-Match header", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var secret *types.Secret
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&secret); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	secret, err = h.ss.UpdateSecret(ctx, &service.UpdateSecretRequest{
+		ParentType:       parentType,
+		ParentRef:        parentRef,
+		SecretName:       secretName,
+		Secret:           secret,
+		ExpectedRevision: expectedRevision,
+	})
+	if err == command.ErrConcurrentUpdate {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
 	if httpError(w, err) {
 		h.log.Errorf("err: %+v", err)
+		return
 	}
-}
\ No newline at end of file
+
+	w.Header().Set("ETag", fmt.Sprintf("\"%d\"", secret.Revision))
+	if err := json.NewEncoder(w).Encode(secret); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
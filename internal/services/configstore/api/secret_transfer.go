@@ -0,0 +1,104 @@
+// Copyright 2019 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/sorintlab/agola/internal/services/configstore/service"
+
+	"go.uber.org/zap"
+)
+
+// ExportSecretsHandler produces an archive of every secret under a
+// parent (plus the secrets it inherits from its ancestors when ?tree is
+// set), for org-to-org migration or disaster-recovery snapshots. The
+// archive carries no signature.
+type ExportSecretsHandler struct {
+	log *zap.SugaredLogger
+	ss  *service.SecretService
+}
+
+func NewExportSecretsHandler(logger *zap.Logger, ss *service.SecretService) *ExportSecretsHandler {
+	return &ExportSecretsHandler{log: logger.Sugar(), ss: ss}
+}
+
+func (h *ExportSecretsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	_, tree := query["tree"]
+	passphrase := query.Get("passphrase")
+
+	parentType, parentRef, err := GetConfigTypeRef(r)
+	if httpError(w, err) {
+		h.log.Errorf("err: %+v", err)
+		return
+	}
+
+	manifest, err := h.ss.ExportSecrets(r.Context(), parentType, parentRef, tree, passphrase)
+	if httpError(w, err) {
+		h.log.Errorf("err: %+v", err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(manifest); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// ImportSecretsHandler recreates the secrets described by a manifest
+// produced by ExportSecretsHandler under a (possibly different) parent.
+// With ?dry_run set, it reports what it would do without writing
+// anything.
+type ImportSecretsHandler struct {
+	log *zap.SugaredLogger
+	ss  *service.SecretService
+}
+
+func NewImportSecretsHandler(logger *zap.Logger, ss *service.SecretService) *ImportSecretsHandler {
+	return &ImportSecretsHandler{log: logger.Sugar(), ss: ss}
+}
+
+func (h *ImportSecretsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	_, dryRun := query["dry_run"]
+	_, overwrite := query["overwrite"]
+	passphrase := query.Get("passphrase")
+	backendName := query.Get("backend")
+
+	parentType, parentRef, err := GetConfigTypeRef(r)
+	if httpError(w, err) {
+		h.log.Errorf("err: %+v", err)
+		return
+	}
+
+	var manifest service.ExportManifest
+	if err := json.NewDecoder(r.Body).Decode(&manifest); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.ss.ImportSecrets(r.Context(), parentType, parentRef, &manifest, passphrase, backendName, overwrite, dryRun)
+	if httpError(w, err) {
+		h.log.Errorf("err: %+v", err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2019 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package command implements the configstore's write side: every
+// mutation goes through a CommandHandler method, which validates the
+// change and applies it within a single readdb transaction.
+package command
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/sorintlab/agola/internal/db"
+	"github.com/sorintlab/agola/internal/services/configstore/readdb"
+
+	"go.uber.org/zap"
+)
+
+type CommandHandler struct {
+	log    *zap.SugaredLogger
+	readDB *readdb.ReadDB
+	db     *db.DB
+}
+
+func NewCommandHandler(logger *zap.Logger, readDB *readdb.ReadDB, d *db.DB) *CommandHandler {
+	return &CommandHandler{log: logger.Sugar(), readDB: readDB, db: d}
+}
+
+func generateID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2019 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sorintlab/agola/internal/db"
+	"github.com/sorintlab/agola/internal/services/configstore/secret"
+	"github.com/sorintlab/agola/internal/services/types"
+)
+
+// MigrateSecretsBackend walks every secret in the configstore and, for
+// any whose BackendRef isn't already on targetBackend, resolves its
+// value through the old backend, re-stores it under targetBackend, and
+// then updates the row with the new BackendRef in a transaction of its
+// own (one per secret, so secrets migrated before a failure stay
+// migrated). It
+// deletes the old backend-side entry (e.g. a Vault KV version) only
+// after the row update commits, so a crash mid-migration never loses a
+// secret.
+func (h *CommandHandler) MigrateSecretsBackend(ctx context.Context, backends *secret.Registry, targetBackend string) error {
+	target, err := backends.Get(targetBackend)
+	if err != nil {
+		return err
+	}
+
+	var secrets []*types.Secret
+	err = h.readDB.Do(func(tx *db.Tx) error {
+		var err error
+		secrets, err = h.readDB.GetAllSecrets(tx)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("listing secrets: %w", err)
+	}
+
+	for _, sec := range secrets {
+		if sec.BackendRef != nil && sec.BackendRef.Backend == targetBackend {
+			continue
+		}
+
+		oldRef := (*secret.BackendRef)(sec.BackendRef)
+		if oldRef == nil {
+			// A row with no BackendRef at all doesn't match any known
+			// schema this command understands how to read a value out
+			// of. Silently treating it as empty would re-store it with
+			// nothing and discard whatever it actually held, so refuse
+			// instead of guessing.
+			return fmt.Errorf("secret %s: has no BackendRef, refusing to migrate: cannot determine its stored value without knowing the pre-backend schema", sec.ID)
+		}
+
+		oldBackend, err := backends.Get(oldRef.Backend)
+		if err != nil {
+			return fmt.Errorf("secret %s: %w", sec.ID, err)
+		}
+
+		data, err := oldBackend.Resolve(ctx, oldRef)
+		if err != nil {
+			return fmt.Errorf("secret %s: resolving under old backend: %w", sec.ID, err)
+		}
+
+		newRef, err := target.Store(ctx, data)
+		if err != nil {
+			return fmt.Errorf("secret %s: storing under %s: %w", sec.ID, targetBackend, err)
+		}
+
+		if err := h.setSecretBackendRef(ctx, sec.ID, (*types.SecretBackendRef)(newRef)); err != nil {
+			return fmt.Errorf("secret %s: updating backend ref: %w", sec.ID, err)
+		}
+
+		if err := oldBackend.Delete(ctx, oldRef); err != nil {
+			h.log.Warnf("secret %s: failed deleting old backend entry: %v", sec.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func (h *CommandHandler) setSecretBackendRef(ctx context.Context, secretID string, ref *types.SecretBackendRef) error {
+	return h.readDB.Do(func(tx *db.Tx) error {
+		return h.readDB.UpdateSecretBackendRef(tx, secretID, ref)
+	})
+}
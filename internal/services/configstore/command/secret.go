@@ -0,0 +1,266 @@
+// Copyright 2019 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sorintlab/agola/internal/db"
+	"github.com/sorintlab/agola/internal/services/types"
+)
+
+// ErrConcurrentUpdate is returned by UpdateSecret when the caller's
+// expected revision doesn't match the stored one; the HTTP API surfaces
+// it as a 409.
+var ErrConcurrentUpdate = errors.New("concurrent update: secret revision mismatch")
+
+// ErrSecretAlreadyExists is returned by CreateSecret and by UpdateSecret
+// (on a reparent) when a secret with the same name already exists under
+// the destination parent.
+var ErrSecretAlreadyExists = errors.New("secret already exists")
+
+func (h *CommandHandler) CreateSecret(ctx context.Context, secret *types.Secret) (*types.Secret, error) {
+	err := h.db.Do(func(tx *db.Tx) error {
+		return h.createSecretTx(tx, secret)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// createSecretTx is the body of CreateSecret, factored out so
+// ImportSecrets can run it against a tx it already owns instead of
+// opening one of its own per secret.
+func (h *CommandHandler) createSecretTx(tx *db.Tx, secret *types.Secret) error {
+	id, err := generateID()
+	if err != nil {
+		return err
+	}
+	secret.ID = id
+	secret.Revision = 1
+
+	parentID, err := h.readDB.ResolveConfigID(tx, secret.Parent.Type, secret.Parent.ID)
+	if err != nil {
+		return err
+	}
+	secret.Parent.ID = parentID
+
+	existing, err := h.readDB.GetSecretByName(tx, parentID, secret.Name)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return ErrSecretAlreadyExists
+	}
+
+	return insertSecret(tx, secret)
+}
+
+func (h *CommandHandler) DeleteSecret(ctx context.Context, parentType types.ConfigType, parentRef, secretName string) error {
+	return h.db.Do(func(tx *db.Tx) error {
+		parentID, err := h.readDB.ResolveConfigID(tx, parentType, parentRef)
+		if err != nil {
+			return err
+		}
+
+		secret, err := h.readDB.GetSecretByName(tx, parentID, secretName)
+		if err != nil {
+			return err
+		}
+		if secret == nil {
+			return fmt.Errorf("secret %q does not exist", secretName)
+		}
+
+		_, err = tx.Exec(`delete from secret where id = ?`, secret.ID)
+		return err
+	})
+}
+
+// checkRevision enforces the If-Match semantics for UpdateSecret: a
+// zero expectedRevision means "don't check" (unconditional update), any
+// other value must match the stored revision exactly.
+func checkRevision(storedRevision, expectedRevision int64) error {
+	if expectedRevision != 0 && storedRevision != expectedRevision {
+		return ErrConcurrentUpdate
+	}
+	return nil
+}
+
+// UpdateSecret applies an in-place value/type change and/or a reparent
+// (moving secretName from parentType/parentRef to newSecret.Parent)
+// atomically: it re-validates the expected revision, checks for a name
+// collision at the destination, and writes the result, all within a
+// single transaction.
+func (h *CommandHandler) UpdateSecret(ctx context.Context, parentType types.ConfigType, parentRef, secretName string, newSecret *types.Secret, expectedRevision int64) (*types.Secret, error) {
+	var updated *types.Secret
+
+	err := h.db.Do(func(tx *db.Tx) error {
+		var err error
+		updated, err = h.updateSecretTx(tx, parentType, parentRef, secretName, newSecret, expectedRevision)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// updateSecretTx is the body of UpdateSecret, factored out so
+// ImportSecrets can run it against a tx it already owns instead of
+// opening one of its own per secret.
+func (h *CommandHandler) updateSecretTx(tx *db.Tx, parentType types.ConfigType, parentRef, secretName string, newSecret *types.Secret, expectedRevision int64) (*types.Secret, error) {
+	parentID, err := h.readDB.ResolveConfigID(tx, parentType, parentRef)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := h.readDB.GetSecretByName(tx, parentID, secretName)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, fmt.Errorf("secret %q does not exist", secretName)
+	}
+
+	if err := checkRevision(existing.Revision, expectedRevision); err != nil {
+		return nil, err
+	}
+
+	destParentType := parentType
+	destParentID := parentID
+	if newSecret.Parent.Type != 0 || newSecret.Parent.ID != "" {
+		destParentType = newSecret.Parent.Type
+		destParentID, err = h.readDB.ResolveConfigID(tx, destParentType, newSecret.Parent.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	destName := newSecret.Name
+	if destName == "" {
+		destName = secretName
+	}
+
+	if destParentID != parentID || destName != secretName {
+		collision, err := h.readDB.GetSecretByName(tx, destParentID, destName)
+		if err != nil {
+			return nil, err
+		}
+		if collision != nil {
+			return nil, ErrSecretAlreadyExists
+		}
+	}
+
+	existing.Name = destName
+	existing.Parent.Type = destParentType
+	existing.Parent.ID = destParentID
+	if newSecret.Type != "" {
+		existing.Type = newSecret.Type
+	}
+	if newSecret.BackendRef != nil {
+		existing.BackendRef = newSecret.BackendRef
+	}
+	prevRevision := existing.Revision
+	existing.Revision++
+
+	n, err := updateSecret(tx, existing, prevRevision)
+	if err != nil {
+		return nil, err
+	}
+	if n != 1 {
+		return nil, ErrConcurrentUpdate
+	}
+
+	return existing, nil
+}
+
+// ImportSecretOp is one write ImportSecrets applies: either a fresh
+// secret to create (Update false) or an in-place update of the existing
+// secret named SecretName under ParentType/ParentRef (Update true).
+// Secret.Data must already be nil with BackendRef pointing at wherever
+// the value was stored, mirroring CreateSecret/UpdateSecret: storing
+// through a secret.Backend is an external call that has no place inside
+// a DB transaction.
+type ImportSecretOp struct {
+	ParentType types.ConfigType
+	ParentRef  string
+	SecretName string
+	Secret     *types.Secret
+	Update     bool
+}
+
+// ImportSecrets applies every op in a single transaction, so a manifest
+// that fails partway through (e.g. a name collision that appeared after
+// the caller classified it) leaves the destination untouched rather than
+// partially imported.
+func (h *CommandHandler) ImportSecrets(ctx context.Context, ops []*ImportSecretOp) ([]*types.Secret, error) {
+	results := make([]*types.Secret, len(ops))
+	err := h.db.Do(func(tx *db.Tx) error {
+		for i, op := range ops {
+			if op.Update {
+				updated, err := h.updateSecretTx(tx, op.ParentType, op.ParentRef, op.SecretName, op.Secret, 0)
+				if err != nil {
+					return fmt.Errorf("secret %s: %w", op.SecretName, err)
+				}
+				results[i] = updated
+				continue
+			}
+			if err := h.createSecretTx(tx, op.Secret); err != nil {
+				return fmt.Errorf("secret %s: %w", op.SecretName, err)
+			}
+			results[i] = op.Secret
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func insertSecret(tx *db.Tx, secret *types.Secret) error {
+	backend, reference := backendRefColumns(secret)
+	_, err := tx.Exec(
+		`insert into secret (id, name, parenttype, parentid, type, backend, reference, revision) values (?, ?, ?, ?, ?, ?, ?, ?)`,
+		secret.ID, secret.Name, secret.Parent.Type, secret.Parent.ID, secret.Type, backend, reference, secret.Revision,
+	)
+	return err
+}
+
+// updateSecret writes secret's fields, guarding the update with
+// expectedRevision so two concurrent updaters reading the same row can't
+// both succeed. It returns the number of rows affected: 0 means the
+// stored revision moved under us since it was read.
+func updateSecret(tx *db.Tx, secret *types.Secret, expectedRevision int64) (int64, error) {
+	backend, reference := backendRefColumns(secret)
+	res, err := tx.Exec(
+		`update secret set name = ?, parenttype = ?, parentid = ?, type = ?, backend = ?, reference = ?, revision = ? where id = ? and revision = ?`,
+		secret.Name, secret.Parent.Type, secret.Parent.ID, secret.Type, backend, reference, secret.Revision, secret.ID, expectedRevision,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func backendRefColumns(secret *types.Secret) (backend, reference string) {
+	if secret.BackendRef != nil {
+		backend, reference = secret.BackendRef.Backend, secret.BackendRef.Reference
+	}
+	return backend, reference
+}
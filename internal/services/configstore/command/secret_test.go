@@ -0,0 +1,39 @@
+// Copyright 2019 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import "testing"
+
+func TestCheckRevision(t *testing.T) {
+	tests := []struct {
+		name             string
+		storedRevision   int64
+		expectedRevision int64
+		wantErr          error
+	}{
+		{name: "no If-Match, any stored revision", storedRevision: 5, expectedRevision: 0, wantErr: nil},
+		{name: "matching revision", storedRevision: 5, expectedRevision: 5, wantErr: nil},
+		{name: "stale revision", storedRevision: 6, expectedRevision: 5, wantErr: ErrConcurrentUpdate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRevision(tt.storedRevision, tt.expectedRevision)
+			if err != tt.wantErr {
+				t.Errorf("checkRevision(%d, %d) = %v, want %v", tt.storedRevision, tt.expectedRevision, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2019 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package readdb holds the configstore's read-side queries: the
+// projections the HTTP/gRPC APIs read from, kept up to date by the
+// command handler's writes.
+package readdb
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/sorintlab/agola/internal/db"
+	"github.com/sorintlab/agola/internal/services/types"
+)
+
+type ReadDB struct {
+	db *db.DB
+}
+
+func NewReadDB(d *db.DB) *ReadDB {
+	return &ReadDB{db: d}
+}
+
+func (r *ReadDB) Do(f func(tx *db.Tx) error) error {
+	return r.db.Do(f)
+}
+
+func (r *ReadDB) GetSecretByID(tx *db.Tx, secretID string) (*types.Secret, error) {
+	row := tx.QueryRow(`select id, name, parenttype, parentid, type, backend, reference, revision from secret where id = ?`, secretID)
+	secret, err := scanSecret(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return secret, err
+}
+
+func (r *ReadDB) GetSecretByName(tx *db.Tx, parentID, name string) (*types.Secret, error) {
+	row := tx.QueryRow(`select id, name, parenttype, parentid, type, backend, reference, revision from secret where parentid = ? and name = ?`, parentID, name)
+	secret, err := scanSecret(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return secret, err
+}
+
+func (r *ReadDB) GetSecrets(tx *db.Tx, parentID string) ([]*types.Secret, error) {
+	rows, err := tx.Query(`select id, name, parenttype, parentid, type, backend, reference, revision from secret where parentid = ? order by name`, parentID)
+	if err != nil {
+		return nil, err
+	}
+	return scanSecrets(rows)
+}
+
+// GetAllSecrets returns every secret in the configstore regardless of
+// parent, used by MigrateSecretsBackend to sweep the whole table.
+func (r *ReadDB) GetAllSecrets(tx *db.Tx) ([]*types.Secret, error) {
+	rows, err := tx.Query(`select id, name, parenttype, parentid, type, backend, reference, revision from secret order by id`)
+	if err != nil {
+		return nil, err
+	}
+	return scanSecrets(rows)
+}
+
+// GetSecretsTree returns every secret visible to parentID: its own
+// secrets plus those of every ancestor up to the root, so a project
+// inherits its org's secrets.
+func (r *ReadDB) GetSecretsTree(tx *db.Tx, parentType types.ConfigType, parentID string) ([]*types.Secret, error) {
+	var secrets []*types.Secret
+	for id := parentID; id != ""; {
+		s, err := r.GetSecrets(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, s...)
+
+		parentOfID, err := r.getParentID(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		id = parentOfID
+	}
+	return secrets, nil
+}
+
+// ResolveConfigID resolves a parent ref, which may already be an ID or
+// may be a name, to the config object's ID.
+func (r *ReadDB) ResolveConfigID(tx *db.Tx, parentType types.ConfigType, parentRef string) (string, error) {
+	var id string
+	err := tx.QueryRow(`select id from configobject where type = ? and (id = ? or name = ?)`, parentType, parentRef, parentRef).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("no config object of type %v with ref %q", parentType, parentRef)
+	}
+	return id, err
+}
+
+func (r *ReadDB) GetParentPath(tx *db.Tx, parentType types.ConfigType, parentID string) (string, error) {
+	var path string
+	err := tx.QueryRow(`select path from configobject where type = ? and id = ?`, parentType, parentID).Scan(&path)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("no config object of type %v with id %q", parentType, parentID)
+	}
+	return path, err
+}
+
+// UpdateSecretBackendRef rewrites the backend/reference columns for
+// secretID in place, without touching its name, parent, or revision.
+// Used by MigrateSecretsBackend once a secret's value has been re-stored
+// under the target backend.
+func (r *ReadDB) UpdateSecretBackendRef(tx *db.Tx, secretID string, ref *types.SecretBackendRef) error {
+	var backend, reference string
+	if ref != nil {
+		backend, reference = ref.Backend, ref.Reference
+	}
+	_, err := tx.Exec(`update secret set backend = ?, reference = ? where id = ?`, backend, reference, secretID)
+	return err
+}
+
+func (r *ReadDB) getParentID(tx *db.Tx, id string) (string, error) {
+	var parentID sql.NullString
+	err := tx.QueryRow(`select parentid from configobject where id = ?`, id).Scan(&parentID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return parentID.String, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSecret(row scanner) (*types.Secret, error) {
+	var s types.Secret
+	var backend, reference string
+	if err := row.Scan(&s.ID, &s.Name, &s.Parent.Type, &s.Parent.ID, &s.Type, &backend, &reference, &s.Revision); err != nil {
+		return nil, err
+	}
+	if backend != "" {
+		s.BackendRef = &types.SecretBackendRef{Backend: backend, Reference: reference}
+	}
+	return &s, nil
+}
+
+func scanSecrets(rows *sql.Rows) ([]*types.Secret, error) {
+	defer rows.Close()
+
+	var secrets []*types.Secret
+	for rows.Next() {
+		s, err := scanSecret(rows)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, s)
+	}
+	return secrets, rows.Err()
+}
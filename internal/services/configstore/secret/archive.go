@@ -0,0 +1,105 @@
+// Copyright 2019 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const scryptKeyLen = 32
+
+// SealedValue is a secret value sealed for an export archive: either
+// with a passphrase-derived key (Salt set, no configured backend), or
+// unsealed by the same secret.Backend the running configstore has
+// configured (BackendRef set instead). Exactly one of the two is set.
+type SealedValue struct {
+	Salt       []byte      `json:"salt,omitempty"`
+	Nonce      []byte      `json:"nonce,omitempty"`
+	Ciphertext []byte      `json:"ciphertext,omitempty"`
+	BackendRef *BackendRef `json:"backendRef,omitempty"`
+}
+
+// SealWithPassphrase encrypts data with a key derived from passphrase
+// via scrypt, for archives created without a configured backend.
+func SealWithPassphrase(data map[string]string, passphrase string) (*SealedValue, error) {
+	plaintext, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, scryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return &SealedValue{
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plaintext, nil),
+	}, nil
+}
+
+// OpenWithPassphrase reverses SealWithPassphrase.
+func OpenWithPassphrase(sv *SealedValue, passphrase string) (map[string]string, error) {
+	if sv.Salt == nil {
+		return nil, fmt.Errorf("secret was not sealed with a passphrase")
+	}
+	key, err := scrypt.Key([]byte(passphrase), sv.Salt, 1<<15, 8, 1, scryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, sv.Nonce, sv.Ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting sealed secret (wrong passphrase?): %w", err)
+	}
+
+	data := map[string]string{}
+	if err := json.Unmarshal(plaintext, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2019 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSealOpenWithPassphraseRoundTrip(t *testing.T) {
+	data := map[string]string{"username": "admin", "password": "s3cr3t"}
+
+	sealed, err := SealWithPassphrase(data, "correct horse battery staple")
+	if err != nil {
+		t.Fatalf("SealWithPassphrase: %v", err)
+	}
+
+	opened, err := OpenWithPassphrase(sealed, "correct horse battery staple")
+	if err != nil {
+		t.Fatalf("OpenWithPassphrase: %v", err)
+	}
+	if !reflect.DeepEqual(opened, data) {
+		t.Errorf("opened = %v, want %v", opened, data)
+	}
+}
+
+func TestOpenWithPassphraseWrongPassphrase(t *testing.T) {
+	sealed, err := SealWithPassphrase(map[string]string{"k": "v"}, "the-right-one")
+	if err != nil {
+		t.Fatalf("SealWithPassphrase: %v", err)
+	}
+
+	if _, err := OpenWithPassphrase(sealed, "the-wrong-one"); err == nil {
+		t.Fatal("OpenWithPassphrase with a wrong passphrase: got nil error, want a decryption error")
+	}
+}
+
+func TestOpenWithPassphraseNotSealedWithOne(t *testing.T) {
+	sv := &SealedValue{BackendRef: &BackendRef{Backend: "vault", Reference: "secret/foo"}}
+
+	if _, err := OpenWithPassphrase(sv, "anything"); err == nil {
+		t.Fatal("OpenWithPassphrase on a BackendRef-sealed value: got nil error, want an error")
+	}
+}
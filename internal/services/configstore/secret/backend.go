@@ -0,0 +1,76 @@
+// Copyright 2019 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package secret defines the pluggable backends that store the actual
+// value of a types.Secret. The configstore DB never holds a raw secret
+// value directly (except with the "inline" backend, kept for backward
+// compatibility): it holds a BackendRef pointing at where the value
+// lives, and a backend resolves that reference into the value on read.
+package secret
+
+import (
+	"context"
+	"fmt"
+)
+
+// BackendRef is what gets persisted on types.Secret in place of a raw
+// value: which backend wrote it, and an opaque reference the backend
+// uses to look the value back up (or decrypt it).
+type BackendRef struct {
+	Backend   string `json:"backend"`
+	Reference string `json:"reference"`
+}
+
+// Backend stores and resolves secret values. Store returns the
+// BackendRef to persist; Resolve turns that ref back into the value.
+// Delete removes any backend-side state (e.g. a Vault KV entry) when
+// the secret is deleted from the configstore.
+type Backend interface {
+	Name() string
+	Store(ctx context.Context, data map[string]string) (*BackendRef, error)
+	Resolve(ctx context.Context, ref *BackendRef) (map[string]string, error)
+	Delete(ctx context.Context, ref *BackendRef) error
+}
+
+// Registry looks up a configured Backend by name. Backends register
+// themselves under the name they were configured with (e.g. "inline",
+// "vault", or an operator-chosen name for a specific kms-envelope
+// configuration).
+type Registry struct {
+	backends map[string]Backend
+	def      string
+}
+
+func NewRegistry(def string) *Registry {
+	return &Registry{backends: map[string]Backend{}, def: def}
+}
+
+func (r *Registry) Register(b Backend) {
+	r.backends[b.Name()] = b
+}
+
+func (r *Registry) Default() (Backend, error) {
+	return r.Get(r.def)
+}
+
+func (r *Registry) Get(name string) (Backend, error) {
+	if name == "" {
+		name = r.def
+	}
+	b, ok := r.backends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown secret backend %q", name)
+	}
+	return b, nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2019 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// InlineBackendName is the backend used by secrets created before
+// pluggable backends existed: the value is stored verbatim in the
+// BackendRef itself, so no external lookup is needed.
+const InlineBackendName = "inline"
+
+// InlineBackend keeps the current behavior of storing the secret value
+// directly in the configstore DB. It exists so every secret, old and
+// new, goes through the same Backend interface.
+type InlineBackend struct{}
+
+func NewInlineBackend() *InlineBackend {
+	return &InlineBackend{}
+}
+
+func (b *InlineBackend) Name() string { return InlineBackendName }
+
+func (b *InlineBackend) Store(ctx context.Context, data map[string]string) (*BackendRef, error) {
+	v, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &BackendRef{Backend: InlineBackendName, Reference: string(v)}, nil
+}
+
+func (b *InlineBackend) Resolve(ctx context.Context, ref *BackendRef) (map[string]string, error) {
+	if ref.Backend != InlineBackendName {
+		return nil, fmt.Errorf("inline backend cannot resolve ref for backend %q", ref.Backend)
+	}
+	data := map[string]string{}
+	if err := json.Unmarshal([]byte(ref.Reference), &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (b *InlineBackend) Delete(ctx context.Context, ref *BackendRef) error {
+	// Nothing to do: the value lives in the ref itself, which the
+	// caller drops along with the secret row.
+	return nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2019 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestInlineBackendStoreResolveRoundTrip(t *testing.T) {
+	b := NewInlineBackend()
+	data := map[string]string{"username": "admin", "password": "s3cr3t"}
+
+	ref, err := b.Store(context.Background(), data)
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if ref.Backend != InlineBackendName {
+		t.Errorf("ref.Backend = %q, want %q", ref.Backend, InlineBackendName)
+	}
+
+	resolved, err := b.Resolve(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if !reflect.DeepEqual(resolved, data) {
+		t.Errorf("resolved = %v, want %v", resolved, data)
+	}
+}
+
+func TestInlineBackendResolveWrongBackend(t *testing.T) {
+	b := NewInlineBackend()
+	ref := &BackendRef{Backend: VaultBackendName, Reference: "secret/foo"}
+
+	if _, err := b.Resolve(context.Background(), ref); err == nil {
+		t.Fatal("Resolve with a non-inline ref: got nil error, want an error")
+	}
+}
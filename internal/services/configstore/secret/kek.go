@@ -0,0 +1,115 @@
+// Copyright 2019 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"context"
+
+	gcpkms "cloud.google.com/go/kms/apiv1"
+	kmspb "cloud.google.com/go/kms/apiv1/kmspb"
+	"filippo.io/age"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+)
+
+// AWSKEKProvider wraps/unwraps DEKs with an AWS KMS customer master key.
+type AWSKEKProvider struct {
+	client *kms.KMS
+	keyID  string
+}
+
+func NewAWSKEKProvider(sess *session.Session, keyID string) *AWSKEKProvider {
+	return &AWSKEKProvider{client: kms.New(sess), keyID: keyID}
+}
+
+func (p *AWSKEKProvider) Name() string { return "aws-kms" }
+
+func (p *AWSKEKProvider) WrapKey(ctx context.Context, dek []byte) ([]byte, error) {
+	out, err := p.client.EncryptWithContext(ctx, &kms.EncryptInput{
+		KeyId:     aws.String(p.keyID),
+		Plaintext: dek,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.CiphertextBlob, nil
+}
+
+func (p *AWSKEKProvider) UnwrapKey(ctx context.Context, wrappedDEK []byte) ([]byte, error) {
+	out, err := p.client.DecryptWithContext(ctx, &kms.DecryptInput{
+		KeyId:          aws.String(p.keyID),
+		CiphertextBlob: wrappedDEK,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
+
+// GCPKEKProvider wraps/unwraps DEKs with a Cloud KMS CryptoKey.
+type GCPKEKProvider struct {
+	client  *gcpkms.KeyManagementClient
+	keyName string
+}
+
+func NewGCPKEKProvider(client *gcpkms.KeyManagementClient, keyName string) *GCPKEKProvider {
+	return &GCPKEKProvider{client: client, keyName: keyName}
+}
+
+func (p *GCPKEKProvider) Name() string { return "gcp-kms" }
+
+func (p *GCPKEKProvider) WrapKey(ctx context.Context, dek []byte) ([]byte, error) {
+	resp, err := p.client.Encrypt(ctx, &kmspb.EncryptRequest{
+		Name:      p.keyName,
+		Plaintext: dek,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Ciphertext, nil
+}
+
+func (p *GCPKEKProvider) UnwrapKey(ctx context.Context, wrappedDEK []byte) ([]byte, error) {
+	resp, err := p.client.Decrypt(ctx, &kmspb.DecryptRequest{
+		Name:       p.keyName,
+		Ciphertext: wrappedDEK,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
+
+// AgeKEKProvider wraps/unwraps DEKs with a local age identity, for
+// operators without a cloud KMS.
+type AgeKEKProvider struct {
+	recipient age.Recipient
+	identity  age.Identity
+}
+
+func NewAgeKEKProvider(recipient age.Recipient, identity age.Identity) *AgeKEKProvider {
+	return &AgeKEKProvider{recipient: recipient, identity: identity}
+}
+
+func (p *AgeKEKProvider) Name() string { return "age" }
+
+func (p *AgeKEKProvider) WrapKey(ctx context.Context, dek []byte) ([]byte, error) {
+	return ageEncrypt(dek, p.recipient)
+}
+
+func (p *AgeKEKProvider) UnwrapKey(ctx context.Context, wrappedDEK []byte) ([]byte, error) {
+	return ageDecrypt(wrappedDEK, p.identity)
+}
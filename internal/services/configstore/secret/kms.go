@@ -0,0 +1,142 @@
+// Copyright 2019 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+const KMSEnvelopeBackendName = "kms-envelope"
+
+// KEKProvider wraps/unwraps a data-encryption key (DEK) with a
+// key-encryption key (KEK) held by an external KMS. Implementations
+// exist for AWS KMS, GCP KMS, and age (for operators without a cloud
+// KMS); each just needs to encrypt/decrypt a 32-byte DEK.
+type KEKProvider interface {
+	Name() string
+	WrapKey(ctx context.Context, dek []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, wrappedDEK []byte) ([]byte, error)
+}
+
+// envelope is what KMSEnvelopeBackend persists as the BackendRef
+// reference: the AES-GCM-wrapped secret data plus the DEK, itself
+// wrapped by the KEK. Only the KEK provider can unwrap the DEK; only
+// the DEK can decrypt Ciphertext.
+type envelope struct {
+	WrappedDEK []byte `json:"wrapped_dek"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// KMSEnvelopeBackend implements envelope encryption at rest: each
+// secret is encrypted locally with a fresh AES-GCM DEK, and the DEK is
+// wrapped by a KEK fetched from the configured KEKProvider. Only the
+// wrapped envelope is stored; the plaintext DEK never leaves memory.
+type KMSEnvelopeBackend struct {
+	name string
+	kek  KEKProvider
+}
+
+func NewKMSEnvelopeBackend(name string, kek KEKProvider) *KMSEnvelopeBackend {
+	return &KMSEnvelopeBackend{name: name, kek: kek}
+}
+
+func (b *KMSEnvelopeBackend) Name() string { return b.name }
+
+func (b *KMSEnvelopeBackend) Store(ctx context.Context, data map[string]string) (*BackendRef, error) {
+	plaintext, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(dek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	ciphertext := gcm.Seal(nil, nonce, plaintext, nil)
+
+	wrappedDEK, err := b.kek.WrapKey(ctx, dek)
+	if err != nil {
+		return nil, fmt.Errorf("wrapping DEK with %s KEK: %w", b.kek.Name(), err)
+	}
+
+	env := envelope{WrappedDEK: wrappedDEK, Nonce: nonce, Ciphertext: ciphertext}
+	envJSON, err := json.Marshal(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BackendRef{Backend: b.name, Reference: base64.StdEncoding.EncodeToString(envJSON)}, nil
+}
+
+func (b *KMSEnvelopeBackend) Resolve(ctx context.Context, ref *BackendRef) (map[string]string, error) {
+	envJSON, err := base64.StdEncoding.DecodeString(ref.Reference)
+	if err != nil {
+		return nil, err
+	}
+	var env envelope
+	if err := json.Unmarshal(envJSON, &env); err != nil {
+		return nil, err
+	}
+
+	dek, err := b.kek.UnwrapKey(ctx, env.WrappedDEK)
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping DEK with %s KEK: %w", b.kek.Name(), err)
+	}
+
+	block, err := aes.NewCipher(dek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, env.Nonce, env.Ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting secret: %w", err)
+	}
+
+	data := map[string]string{}
+	if err := json.Unmarshal(plaintext, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (b *KMSEnvelopeBackend) Delete(ctx context.Context, ref *BackendRef) error {
+	// The envelope lives entirely in the ref; there's no external
+	// state to clean up beyond dropping the secret row itself.
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2019 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// fakeKEKProvider "wraps" a DEK by XOR-ing it with a fixed pad, so tests
+// don't need a real KMS; UnwrapKey reverses the same XOR.
+type fakeKEKProvider struct {
+	pad       []byte
+	unwrapErr error
+}
+
+func (p *fakeKEKProvider) Name() string { return "fake" }
+
+func (p *fakeKEKProvider) WrapKey(ctx context.Context, dek []byte) ([]byte, error) {
+	return xorWithPad(dek, p.pad), nil
+}
+
+func (p *fakeKEKProvider) UnwrapKey(ctx context.Context, wrappedDEK []byte) ([]byte, error) {
+	if p.unwrapErr != nil {
+		return nil, p.unwrapErr
+	}
+	return xorWithPad(wrappedDEK, p.pad), nil
+}
+
+func xorWithPad(b, pad []byte) []byte {
+	out := make([]byte, len(b))
+	for i := range b {
+		out[i] = b[i] ^ pad[i%len(pad)]
+	}
+	return out
+}
+
+func TestKMSEnvelopeBackendStoreResolveRoundTrip(t *testing.T) {
+	kek := &fakeKEKProvider{pad: []byte("fixed-test-pad-")}
+	b := NewKMSEnvelopeBackend(KMSEnvelopeBackendName, kek)
+	data := map[string]string{"username": "admin", "password": "s3cr3t"}
+
+	ref, err := b.Store(context.Background(), data)
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if ref.Backend != KMSEnvelopeBackendName {
+		t.Errorf("ref.Backend = %q, want %q", ref.Backend, KMSEnvelopeBackendName)
+	}
+
+	resolved, err := b.Resolve(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if !reflect.DeepEqual(resolved, data) {
+		t.Errorf("resolved = %v, want %v", resolved, data)
+	}
+}
+
+func TestKMSEnvelopeBackendResolveKEKFailure(t *testing.T) {
+	kek := &fakeKEKProvider{pad: []byte("fixed-test-pad-")}
+	b := NewKMSEnvelopeBackend(KMSEnvelopeBackendName, kek)
+
+	ref, err := b.Store(context.Background(), map[string]string{"k": "v"})
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+
+	kek.unwrapErr = errors.New("kms unavailable")
+	if _, err := b.Resolve(context.Background(), ref); err == nil {
+		t.Fatal("Resolve with a failing KEK provider: got nil error, want an error")
+	}
+}
+
+func TestXorWithPadRoundTrip(t *testing.T) {
+	pad := []byte("pad")
+	in := []byte("some dek bytes!!")
+	wrapped := xorWithPad(in, pad)
+	if bytes.Equal(wrapped, in) {
+		t.Fatal("xorWithPad did not change the input")
+	}
+	if !bytes.Equal(xorWithPad(wrapped, pad), in) {
+		t.Fatal("xorWithPad(xorWithPad(x)) != x")
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2019 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"context"
+	"fmt"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+const VaultBackendName = "vault"
+
+// VaultConfig configures a VaultBackend against a KV v2 secrets engine.
+type VaultConfig struct {
+	Addr       string
+	Token      string
+	MountPath  string // e.g. "secret" for the default KV v2 mount
+	PathPrefix string // e.g. "agola/" so refs don't collide with other apps
+}
+
+// VaultBackend stores secret values in a HashiCorp Vault KV v2 mount.
+// The BackendRef.Reference is the KV path the value was written under;
+// the value itself never touches the configstore DB.
+type VaultBackend struct {
+	client     *vaultapi.Client
+	mountPath  string
+	pathPrefix string
+}
+
+func NewVaultBackend(cfg *VaultConfig) (*VaultBackend, error) {
+	client, err := vaultapi.NewClient(&vaultapi.Config{Address: cfg.Addr})
+	if err != nil {
+		return nil, fmt.Errorf("creating vault client: %w", err)
+	}
+	client.SetToken(cfg.Token)
+
+	return &VaultBackend{
+		client:     client,
+		mountPath:  cfg.MountPath,
+		pathPrefix: cfg.PathPrefix,
+	}, nil
+}
+
+func (b *VaultBackend) Name() string { return VaultBackendName }
+
+func (b *VaultBackend) Store(ctx context.Context, data map[string]string) (*BackendRef, error) {
+	path, err := randomPath(b.pathPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	kvData := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		kvData[k] = v
+	}
+
+	if _, err := b.client.KVv2(b.mountPath).Put(ctx, path, kvData); err != nil {
+		return nil, fmt.Errorf("writing secret to vault: %w", err)
+	}
+
+	return &BackendRef{Backend: VaultBackendName, Reference: path}, nil
+}
+
+func (b *VaultBackend) Resolve(ctx context.Context, ref *BackendRef) (map[string]string, error) {
+	s, err := b.client.KVv2(b.mountPath).Get(ctx, ref.Reference)
+	if err != nil {
+		return nil, fmt.Errorf("reading secret from vault: %w", err)
+	}
+
+	data := make(map[string]string, len(s.Data))
+	for k, v := range s.Data {
+		sv, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected non-string value for key %q at vault path %q", k, ref.Reference)
+		}
+		data[k] = sv
+	}
+	return data, nil
+}
+
+func (b *VaultBackend) Delete(ctx context.Context, ref *BackendRef) error {
+	if err := b.client.KVv2(b.mountPath).DeleteMetadata(ctx, ref.Reference); err != nil {
+		return fmt.Errorf("deleting secret from vault: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+// Copyright 2019 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package service holds the transport-independent configstore secret
+// logic shared by the HTTP and gRPC API surfaces, so both stay in
+// lockstep against the same readdb/command implementation.
+package service
+
+import (
+	"context"
+
+	"github.com/sorintlab/agola/internal/db"
+	"github.com/sorintlab/agola/internal/services/configstore/command"
+	"github.com/sorintlab/agola/internal/services/configstore/readdb"
+	"github.com/sorintlab/agola/internal/services/configstore/secret"
+	"github.com/sorintlab/agola/internal/services/types"
+
+	"go.uber.org/zap"
+)
+
+// SecretService implements the secret business logic on top of a
+// readdb.ReadDB (reads) and a command.CommandHandler (writes). It's
+// consumed by both the HTTP handlers and the gRPC service so neither
+// transport has its own copy of the logic.
+//
+// Secret values themselves are never stored directly on types.Secret:
+// CreateSecret writes the value through the requested secret.Backend
+// and persists only the resulting BackendRef, and reads resolve that
+// ref back into the value lazily through the backends registry.
+type SecretService struct {
+	log      *zap.SugaredLogger
+	readDB   *readdb.ReadDB
+	ch       *command.CommandHandler
+	backends *secret.Registry
+}
+
+func NewSecretService(logger *zap.Logger, readDB *readdb.ReadDB, ch *command.CommandHandler, backends *secret.Registry) *SecretService {
+	return &SecretService{log: logger.Sugar(), readDB: readDB, ch: ch, backends: backends}
+}
+
+func (s *SecretService) resolveValue(ctx context.Context, sec *types.Secret) error {
+	if sec == nil || sec.BackendRef == nil {
+		return nil
+	}
+	b, err := s.backends.Get(sec.BackendRef.Backend)
+	if err != nil {
+		return err
+	}
+	data, err := b.Resolve(ctx, (*secret.BackendRef)(sec.BackendRef))
+	if err != nil {
+		return err
+	}
+	sec.Data = data
+	return nil
+}
+
+func (s *SecretService) GetSecretByID(ctx context.Context, secretID string) (*types.Secret, error) {
+	var sec *types.Secret
+	err := s.readDB.Do(func(tx *db.Tx) error {
+		var err error
+		sec, err = s.readDB.GetSecretByID(tx, secretID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.resolveValue(ctx, sec); err != nil {
+		return nil, err
+	}
+	return sec, nil
+}
+
+func (s *SecretService) GetSecrets(ctx context.Context, parentType types.ConfigType, parentRef string, tree bool) ([]*types.Secret, error) {
+	var secrets []*types.Secret
+	err := s.readDB.Do(func(tx *db.Tx) error {
+		parentID, err := s.readDB.ResolveConfigID(tx, parentType, parentRef)
+		if err != nil {
+			return err
+		}
+		if tree {
+			secrets, err = s.readDB.GetSecretsTree(tx, parentType, parentID)
+		} else {
+			secrets, err = s.readDB.GetSecrets(tx, parentID)
+		}
+		if err != nil {
+			return err
+		}
+		for _, sec := range secrets {
+			pp, err := s.readDB.GetParentPath(tx, sec.Parent.Type, sec.Parent.ID)
+			if err != nil {
+				return err
+			}
+			sec.Parent.Path = pp
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, sec := range secrets {
+		if err := s.resolveValue(ctx, sec); err != nil {
+			return nil, err
+		}
+	}
+	return secrets, nil
+}
+
+// CreateSecret writes sec.Data through the named backend (the registry's
+// default when backendName is empty) and persists only the resulting
+// BackendRef; the plaintext value never reaches the configstore DB for
+// any backend but "inline".
+func (s *SecretService) CreateSecret(ctx context.Context, sec *types.Secret, backendName string) (*types.Secret, error) {
+	b, err := s.backends.Get(backendName)
+	if err != nil {
+		return nil, err
+	}
+
+	ref, err := b.Store(ctx, sec.Data)
+	if err != nil {
+		return nil, err
+	}
+	sec.BackendRef = (*types.SecretBackendRef)(ref)
+	sec.Data = nil
+
+	created, err := s.ch.CreateSecret(ctx, sec)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.resolveValue(ctx, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (s *SecretService) DeleteSecret(ctx context.Context, parentType types.ConfigType, parentRef, secretName string) error {
+	existing, err := s.getStoredSecret(parentType, parentRef, secretName)
+	if err != nil {
+		return err
+	}
+	if err := s.ch.DeleteSecret(ctx, parentType, parentRef, secretName); err != nil {
+		return err
+	}
+	if existing == nil || existing.BackendRef == nil {
+		return nil
+	}
+	b, err := s.backends.Get(existing.BackendRef.Backend)
+	if err == nil {
+		err = b.Delete(ctx, (*secret.BackendRef)(existing.BackendRef))
+	}
+	if err != nil {
+		s.log.Warnf("secret %s: failed deleting backend entry: %v", existing.ID, err)
+	}
+	return nil
+}
+
+// UpdateSecretRequest describes an in-place value/type change and/or a
+// reparent (moving the secret under a new parent). ExpectedRevision, when
+// non-zero, is checked against the stored revision so the update can be
+// rejected with command.ErrConcurrentUpdate on a mismatch (surfaced as a
+// 409 by the HTTP handler).
+type UpdateSecretRequest struct {
+	ParentType       types.ConfigType
+	ParentRef        string
+	SecretName       string
+	Secret           *types.Secret
+	ExpectedRevision int64
+}
+
+// UpdateSecret, like CreateSecret, writes a changed value through the
+// backend before persisting anything: when req.Secret.Data is set it's
+// re-stored under the same backend the secret already used (looked up
+// from the stored row, not the request body), unless req.Secret.BackendRef
+// names a different one explicitly, and req.Secret.BackendRef is set to
+// the result. A request that only renames or reparents the secret (Data
+// left nil) passes through with BackendRef unset, which leaves the
+// existing stored value untouched.
+func (s *SecretService) UpdateSecret(ctx context.Context, req *UpdateSecretRequest) (*types.Secret, error) {
+	if req.Secret.Data != nil {
+		backendName := ""
+		if req.Secret.BackendRef != nil {
+			backendName = req.Secret.BackendRef.Backend
+		} else {
+			existing, err := s.getStoredSecret(req.ParentType, req.ParentRef, req.SecretName)
+			if err != nil {
+				return nil, err
+			}
+			if existing != nil && existing.BackendRef != nil {
+				backendName = existing.BackendRef.Backend
+			}
+		}
+		b, err := s.backends.Get(backendName)
+		if err != nil {
+			return nil, err
+		}
+		ref, err := b.Store(ctx, req.Secret.Data)
+		if err != nil {
+			return nil, err
+		}
+		req.Secret.BackendRef = (*types.SecretBackendRef)(ref)
+		req.Secret.Data = nil
+	}
+
+	updated, err := s.ch.UpdateSecret(ctx, req.ParentType, req.ParentRef, req.SecretName, req.Secret, req.ExpectedRevision)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.resolveValue(ctx, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// getStoredSecret looks up secretName under parentType/parentRef as it
+// currently exists in the DB, without resolving its value through a
+// backend. Returns nil, nil if it doesn't exist.
+func (s *SecretService) getStoredSecret(parentType types.ConfigType, parentRef, secretName string) (*types.Secret, error) {
+	var sec *types.Secret
+	err := s.readDB.Do(func(tx *db.Tx) error {
+		parentID, err := s.readDB.ResolveConfigID(tx, parentType, parentRef)
+		if err != nil {
+			return err
+		}
+		sec, err = s.readDB.GetSecretByName(tx, parentID, secretName)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sec, nil
+}
@@ -0,0 +1,215 @@
+// Copyright 2019 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sorintlab/agola/internal/services/configstore/command"
+	"github.com/sorintlab/agola/internal/services/configstore/secret"
+	"github.com/sorintlab/agola/internal/services/types"
+)
+
+// ExportedSecret is one entry in an ExportManifest: enough metadata to
+// recreate the secret plus its sealed value.
+type ExportedSecret struct {
+	Name       string              `json:"name"`
+	Type       types.SecretType    `json:"type"`
+	ParentType types.ConfigType    `json:"parentType"`
+	ParentPath string              `json:"parentPath"`
+	Sealed     *secret.SealedValue `json:"sealed"`
+}
+
+// ExportManifest is the archive format produced by ExportSecrets and
+// consumed by ImportSecrets.
+type ExportManifest struct {
+	Version int               `json:"version"`
+	Secrets []*ExportedSecret `json:"secrets"`
+}
+
+// ExportSecrets builds a manifest of every secret under parentType/
+// parentRef (plus those inherited from its ancestors when tree is true).
+// When passphrase is empty each secret's own BackendRef is copied as-is,
+// since it already points at durable backend-side storage, and a secret
+// on the inline backend (or with no BackendRef) makes the export fail;
+// otherwise each value is re-sealed with a passphrase-derived key so the
+// archive is self-contained.
+func (s *SecretService) ExportSecrets(ctx context.Context, parentType types.ConfigType, parentRef string, tree bool, passphrase string) (*ExportManifest, error) {
+	secrets, err := s.GetSecrets(ctx, parentType, parentRef, tree)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &ExportManifest{Version: 1, Secrets: make([]*ExportedSecret, 0, len(secrets))}
+	for _, sec := range secrets {
+		// The inline backend's BackendRef.Reference IS the plaintext
+		// value, so it can only be carried into the archive as-is
+		// when it's been re-sealed with a passphrase; every other
+		// backend already stores an opaque reference, so its ref can
+		// be copied through unencrypted.
+		inline := sec.BackendRef == nil || sec.BackendRef.Backend == secret.InlineBackendName
+
+		var sealed *secret.SealedValue
+		switch {
+		case passphrase != "":
+			sealed, err = secret.SealWithPassphrase(sec.Data, passphrase)
+			if err != nil {
+				return nil, fmt.Errorf("secret %s: %w", sec.Name, err)
+			}
+		case inline:
+			return nil, fmt.Errorf("secret %s: a passphrase is required to export secrets stored on the %s backend", sec.Name, secret.InlineBackendName)
+		default:
+			sealed = &secret.SealedValue{BackendRef: (*secret.BackendRef)(sec.BackendRef)}
+		}
+
+		manifest.Secrets = append(manifest.Secrets, &ExportedSecret{
+			Name:       sec.Name,
+			Type:       sec.Type,
+			ParentType: sec.Parent.Type,
+			ParentPath: sec.Parent.Path,
+			Sealed:     sealed,
+		})
+	}
+	return manifest, nil
+}
+
+// ImportResult reports what ImportSecrets did (or, in dry-run mode,
+// would do) without needing the caller to diff the manifest itself.
+type ImportResult struct {
+	Created   []string `json:"created"`
+	Updated   []string `json:"updated"`
+	Conflicts []string `json:"conflicts"`
+}
+
+// importAction is what classifyImportEntry decided to do with one
+// manifest entry.
+type importAction int
+
+const (
+	importCreate importAction = iota
+	importUpdate
+	importConflict
+)
+
+// classifyImportEntry decides whether name should be created, updated,
+// or reported as a conflict, against seen — the set of names that
+// already exist at the destination plus every name already classified
+// earlier in the same import. It marks name as seen either way, so a
+// second manifest entry with the same name is diffed against the first
+// one's outcome rather than only against the destination's state before
+// the import started.
+func classifyImportEntry(seen map[string]bool, name string, overwrite bool) importAction {
+	exists := seen[name]
+	seen[name] = true
+	switch {
+	case exists && !overwrite:
+		return importConflict
+	case exists:
+		return importUpdate
+	default:
+		return importCreate
+	}
+}
+
+// ImportSecrets recreates every secret in manifest under parentType/
+// parentRef, storing values under destBackend (the registry's default
+// when empty) regardless of what backend they were sealed under in the
+// manifest, mirroring the ?backend= param on the create handler. A name
+// that already exists there is reported as a conflict and left
+// untouched unless overwrite is set, in which case it's updated in
+// place. With dryRun set, no writes happen: the returned ImportResult is
+// only the diff the caller would get by running for real. Otherwise
+// every create/update is committed through command.CommandHandler in a
+// single transaction, so a failure partway through the manifest leaves
+// the destination untouched rather than partially imported.
+func (s *SecretService) ImportSecrets(ctx context.Context, parentType types.ConfigType, parentRef string, manifest *ExportManifest, passphrase, destBackend string, overwrite, dryRun bool) (*ImportResult, error) {
+	existing, err := s.GetSecrets(ctx, parentType, parentRef, false)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(existing))
+	for _, sec := range existing {
+		seen[sec.Name] = true
+	}
+
+	b, err := s.backends.Get(destBackend)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{}
+	var ops []*command.ImportSecretOp
+	for _, es := range manifest.Secrets {
+		action := classifyImportEntry(seen, es.Name, overwrite)
+		switch action {
+		case importConflict:
+			result.Conflicts = append(result.Conflicts, es.Name)
+			continue
+		case importUpdate:
+			result.Updated = append(result.Updated, es.Name)
+		default:
+			result.Created = append(result.Created, es.Name)
+		}
+
+		if dryRun {
+			continue
+		}
+
+		data, err := unsealExportedSecret(ctx, s.backends, es, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("secret %s: %w", es.Name, err)
+		}
+
+		ref, err := b.Store(ctx, data)
+		if err != nil {
+			return nil, fmt.Errorf("secret %s: storing under %s: %w", es.Name, destBackend, err)
+		}
+
+		sec := &types.Secret{Name: es.Name, Type: es.Type, BackendRef: (*types.SecretBackendRef)(ref)}
+		sec.Parent.Type = parentType
+		sec.Parent.ID = parentRef
+
+		ops = append(ops, &command.ImportSecretOp{
+			ParentType: parentType,
+			ParentRef:  parentRef,
+			SecretName: es.Name,
+			Secret:     sec,
+			Update:     action == importUpdate,
+		})
+	}
+
+	if len(ops) > 0 {
+		if _, err := s.ch.ImportSecrets(ctx, ops); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+func unsealExportedSecret(ctx context.Context, backends *secret.Registry, es *ExportedSecret, passphrase string) (map[string]string, error) {
+	if es.Sealed == nil {
+		return nil, fmt.Errorf("missing sealed value")
+	}
+	if es.Sealed.BackendRef != nil {
+		b, err := backends.Get(es.Sealed.BackendRef.Backend)
+		if err != nil {
+			return nil, err
+		}
+		return b.Resolve(ctx, es.Sealed.BackendRef)
+	}
+	return secret.OpenWithPassphrase(es.Sealed, passphrase)
+}
@@ -0,0 +1,113 @@
+// Copyright 2019 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/sorintlab/agola/internal/services/configstore/secret"
+)
+
+func TestUnsealExportedSecretMissingSealed(t *testing.T) {
+	es := &ExportedSecret{Name: "nosealed"}
+
+	if _, err := unsealExportedSecret(context.Background(), secret.NewRegistry(""), es, "whatever"); err == nil {
+		t.Fatal("unsealExportedSecret with a nil Sealed value: got nil error, want an error")
+	}
+}
+
+func TestUnsealExportedSecretPassphrase(t *testing.T) {
+	data := map[string]string{"k": "v"}
+	sealed, err := secret.SealWithPassphrase(data, "hunter2")
+	if err != nil {
+		t.Fatalf("SealWithPassphrase: %v", err)
+	}
+	es := &ExportedSecret{Name: "s", Sealed: sealed}
+
+	got, err := unsealExportedSecret(context.Background(), secret.NewRegistry(""), es, "hunter2")
+	if err != nil {
+		t.Fatalf("unsealExportedSecret: %v", err)
+	}
+	if !reflect.DeepEqual(got, data) {
+		t.Errorf("got = %v, want %v", got, data)
+	}
+}
+
+func TestClassifyImportEntry(t *testing.T) {
+	tests := []struct {
+		name      string
+		seed      map[string]bool
+		overwrite bool
+		want      importAction
+	}{
+		{name: "new name", seed: map[string]bool{}, overwrite: false, want: importCreate},
+		{name: "existing name, no overwrite", seed: map[string]bool{"s": true}, overwrite: false, want: importConflict},
+		{name: "existing name, overwrite", seed: map[string]bool{"s": true}, overwrite: true, want: importUpdate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyImportEntry(tt.seed, "s", tt.overwrite)
+			if got != tt.want {
+				t.Errorf("classifyImportEntry(...) = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyImportEntryDuplicateNameInSameManifest(t *testing.T) {
+	seen := map[string]bool{}
+
+	// The first entry named "s" is new...
+	if got := classifyImportEntry(seen, "s", false); got != importCreate {
+		t.Fatalf("first entry: got %v, want importCreate", got)
+	}
+	// ...but a second entry with the same name, later in the same
+	// manifest, must be diffed against the first, not against the
+	// destination's pre-import state (where "s" didn't exist yet).
+	if got := classifyImportEntry(seen, "s", false); got != importConflict {
+		t.Fatalf("duplicate entry without overwrite: got %v, want importConflict", got)
+	}
+
+	seen = map[string]bool{}
+	classifyImportEntry(seen, "s", true)
+	if got := classifyImportEntry(seen, "s", true); got != importUpdate {
+		t.Fatalf("duplicate entry with overwrite: got %v, want importUpdate", got)
+	}
+}
+
+func TestUnsealExportedSecretBackendRef(t *testing.T) {
+	data := map[string]string{"k": "v"}
+	inline := secret.NewInlineBackend()
+	ref, err := inline.Store(context.Background(), data)
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+
+	registry := secret.NewRegistry(inline.Name())
+	registry.Register(inline)
+
+	es := &ExportedSecret{Name: "s", Sealed: &secret.SealedValue{BackendRef: ref}}
+
+	got, err := unsealExportedSecret(context.Background(), registry, es, "")
+	if err != nil {
+		t.Fatalf("unsealExportedSecret: %v", err)
+	}
+	if !reflect.DeepEqual(got, data) {
+		t.Errorf("got = %v, want %v", got, data)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2019 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// ConfigType identifies what kind of config object owns a secret (or
+// variable): an org, a project, or a project group.
+type ConfigType int
+
+const (
+	ConfigTypeProjectGroup ConfigType = iota
+	ConfigTypeProject
+	ConfigTypeOrg
+)
+
+type SecretType string
+
+const (
+	SecretTypeInternal SecretType = "internal"
+)
+
+// Parent identifies the config object a secret is attached to. Path is
+// populated on read (it requires walking the config tree) and isn't
+// persisted.
+type Parent struct {
+	Type ConfigType `json:"type"`
+	ID   string     `json:"id"`
+	Path string     `json:"path,omitempty"`
+}
+
+// SecretBackendRef has the same shape as secret.BackendRef. It's
+// declared independently here, rather than importing the
+// configstore/secret package, so the domain type doesn't depend on one
+// specific consumer's storage abstraction; the two convert directly
+// since their underlying types are identical.
+type SecretBackendRef struct {
+	Backend   string `json:"backend"`
+	Reference string `json:"reference"`
+}
+
+// Secret is a configstore secret. Since the pluggable secret backends
+// were added, its value is never stored directly: BackendRef points at
+// wherever the configured secret.Backend actually keeps it, and Data is
+// populated lazily on read by resolving that ref.
+type Secret struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Parent     Parent            `json:"parent"`
+	Type       SecretType        `json:"type"`
+	Data       map[string]string `json:"data,omitempty"`
+	BackendRef *SecretBackendRef `json:"backend_ref,omitempty"`
+	// Revision is bumped on every UpdateSecret and used as the If-Match
+	// ETag for optimistic concurrency on it. A backend migration rewrites
+	// BackendRef without bumping it.
+	Revision int64 `json:"revision"`
+}